@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+
+	"github.com/milanaleksic/jenkins_ping/jenkins"
+)
+
+// Api is the set of Jenkins operations a Controller needs, backed by either a real
+// ServerAPI or a MockAPI.
+type Api interface {
+	GetLastBuildURLForJob(job string) string
+	GetLastCompletedBuildURLForJob(job string) string
+	GetCurrentStatus(job string) (*jenkins.JobStatus, error)
+	GetStatusForJob(job string, id string) (*jenkins.JobStatus, error)
+	GetKnownJobs() (*jenkins.Status, error)
+	Causes(status *jenkins.JobStatus) []string
+	CausesOfFailures(name, id string) []string
+	CausesOfPreviousFailures(name string) []string
+	GetFailedTestListFor(job, id string) ([]jenkins.TestCase, error)
+	GetFailedTestList(job string) ([]jenkins.TestCase, error)
+	StreamConsole(ctx context.Context, job, id string, out chan<- string) error
+	GetLastLogLines(job, id string, lineCount int) ([]string, error)
+	RunJob(job string) error
+	RunJobWithParams(job string, params map[string]string) (string, error)
+	GetQueueItem(location string) (*jenkins.QueueItem, error)
+}
+
+// JenkinsApi is the real Api implementation, talking to a live Jenkins server.
+type JenkinsApi = jenkins.ServerAPI
+
+// MockApi is a mocked Api implementation used to see how the program behaves without a
+// real Jenkins server.
+type MockApi = jenkins.MockAPI
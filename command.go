@@ -0,0 +1,19 @@
+package main
+
+// Command is a single user action dispatched from a View onto mainLoop's feedback channel.
+type Command struct {
+	group int
+	job   string
+}
+
+// Command groups identify which action mainLoop should take for a received Command.
+const (
+	CmdShutdownGroup = iota
+	CmdCloseGroup
+	CmdShowHelpGroup
+	CmdOpenCurrentJobGroup
+	CmdOpenPreviousJobGroup
+	CmdTestsForJobGroup
+	CmdRunJobGroup
+	CmdStreamLogsGroup
+)
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Controller mediates between a View and an Api, translating user Commands into Api calls.
+type Controller struct {
+	View      View
+	API       Api
+	KnownJobs []string
+
+	streamMu     sync.Mutex
+	streamCancel context.CancelFunc
+}
+
+// ParamPrompter is implemented by Views that can collect build parameter values from the
+// user, e.g. the CUI. Views without it (like the simple console interface) are skipped, and
+// the job is triggered without parameters.
+type ParamPrompter interface {
+	PromptForParams(job string) map[string]string
+}
+
+// RunJobWithParams prompts for parameter values via the View when it supports it, triggers
+// job, and returns; a background goroutine waits for Jenkins to schedule the resulting queue
+// item onto an executor and logs the build number.
+func (c *Controller) RunJobWithParams(job string) {
+	var params map[string]string
+	if prompter, ok := c.View.(ParamPrompter); ok {
+		params = prompter.PromptForParams(job)
+	}
+	location, err := c.API.RunJobWithParams(job, params)
+	if err != nil {
+		log.Printf("Could not run job %s: %v\n", job, err)
+		return
+	}
+	go c.awaitQueuedBuild(job, location)
+}
+
+// awaitQueuedBuild polls location until Jenkins assigns the queued item a build number.
+func (c *Controller) awaitQueuedBuild(job, location string) {
+	item, err := c.API.GetQueueItem(location)
+	if err != nil {
+		log.Printf("Could not resolve queued build for %s: %v\n", job, err)
+		return
+	}
+	log.Printf("Job %s started as build #%d\n", job, item.Executable.Number)
+}
+
+// LogStreamer is implemented by Views that can render streamed console lines as they
+// arrive, e.g. the CUI. Views without it fall back to the regular log output.
+type LogStreamer interface {
+	PresentStreamedLine(job, line string)
+}
+
+// StreamLogs follows job's last build console output live via the View when it supports
+// LogStreamer, so the user can watch a running build in real time. It is long-running
+// (it blocks until the build finishes or StopStreaming cancels it), so callers should run it
+// in its own goroutine rather than from mainLoop's select.
+func (c *Controller) StreamLogs(job string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.streamMu.Lock()
+	c.streamCancel = cancel
+	c.streamMu.Unlock()
+	defer cancel()
+
+	out := make(chan string)
+	streamer, hasStreamer := c.View.(LogStreamer)
+	go func() {
+		for line := range out {
+			if hasStreamer {
+				streamer.PresentStreamedLine(job, line)
+			} else {
+				log.Println(line)
+			}
+		}
+	}()
+	err := c.API.StreamConsole(ctx, job, "lastBuild", out)
+	close(out)
+	if err != nil {
+		log.Printf("Could not stream logs for %s: %v\n", job, err)
+	}
+}
+
+// StopStreaming cancels any console stream started by StreamLogs, so the user can stop
+// watching a build without waiting for it to finish.
+func (c *Controller) StopStreaming() {
+	c.streamMu.Lock()
+	cancel := c.streamCancel
+	c.streamMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
@@ -1,6 +1,7 @@
 package jenkins
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"time"
@@ -140,7 +141,32 @@ func (api *MockAPI) GetLastLogLines(job, id string, lineCount int) ([]string, er
 	}, nil
 }
 
+// StreamConsole is a MOCK for call that follows a build's console output live
+func (api *MockAPI) StreamConsole(ctx context.Context, job, id string, out chan<- string) error {
+	for i := 1; i <= 3; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case out <- fmt.Sprintf("line%d", i):
+		}
+	}
+	return nil
+}
+
 // RunJob will execute a job (expected - without parameters)
 func (api *MockAPI) RunJob(job string) error {
 	return nil
+}
+
+// RunJobWithParams is a MOCK for call that triggers a parameterized build
+func (api *MockAPI) RunJobWithParams(job string, params map[string]string) (string, error) {
+	return fmt.Sprintf("http://mock_jenkins/queue/item/%d/", rand.Intn(1000)), nil
+}
+
+// GetQueueItem is a MOCK for call that resolves a queued build to its eventual build number
+func (api *MockAPI) GetQueueItem(location string) (*QueueItem, error) {
+	return &QueueItem{
+		ID:         int64(rand.Intn(1000)),
+		Executable: &Executable{Number: rand.Intn(100)},
+	}, nil
 }
\ No newline at end of file
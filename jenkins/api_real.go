@@ -1,45 +1,128 @@
 package jenkins
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
 	lastCompletedBuild = "lastCompletedBuild"
 	lastBuild          = "lastBuild"
-	sizeOfSuffix       = 2048
+	// defaultMaxSubJobsLayer and defaultNewestSubJobsEachLayer are used when ServerAPI's
+	// corresponding fields are left at their zero value.
+	defaultMaxSubJobsLayer        = 3
+	defaultNewestSubJobsEachLayer = 10
 )
 
-var (
-	errStatusPageNotFound            = errors.New("Not Found")
-	matcherForHTMLAndWeirdCharacters = regexp.MustCompile(`(<[^>]+>)|(\r)`)
-)
+var matcherForHTMLAndWeirdCharacters = regexp.MustCompile(`(<[^>]+>)|(\r)`)
 
 // ServerAPI is a real-life implementation of the API which connects to a real Jenkins server.
 // Use the given "ServerLocation" field to set the location of the server.
 type ServerAPI struct {
 	ServerLocation string
-	Username string
-	Password string
+	Username       string
+	Password       string
+	// MaxSubJobsLayer bounds how many Folder/Multibranch Pipeline levels GetKnownJobs
+	// descends into. Zero means defaultMaxSubJobsLayer.
+	MaxSubJobsLayer int
+	// NewestSubJobsEachLayer caps how many jobs are fetched per folder level, so a large
+	// multibranch pipeline can't make a single GetKnownJobs call explode. Zero means
+	// defaultNewestSubJobsEachLayer.
+	NewestSubJobsEachLayer int
+	// ResponseTimeout bounds how long any single HTTP call to the server may take. Zero
+	// means defaultResponseTimeout.
+	ResponseTimeout time.Duration
+	// TLSConfig controls certificate validation when ServerLocation uses https://, e.g. to
+	// talk to a server with a self-signed certificate.
+	TLSConfig TLSConfig
+	// MaxRetryAttempts bounds how many attempts in total a request gets while it keeps failing
+	// transiently (ErrServer, ErrTransport, ErrRateLimited). Zero means defaultMaxAttempts.
+	MaxRetryAttempts int
+
 	cachedStatuses map[string](*JobStatus)
+	client         *client
+}
+
+// NewServerAPI builds a ServerAPI with its HTTP client configured up front, failing fast on
+// a bad TLSConfig rather than on the first call made against the server.
+func NewServerAPI(serverLocation, username, password string, responseTimeout time.Duration, tlsConfig TLSConfig, maxRetryAttempts int) (*ServerAPI, error) {
+	api := &ServerAPI{
+		ServerLocation:   serverLocation,
+		Username:         username,
+		Password:         password,
+		ResponseTimeout:  responseTimeout,
+		TLSConfig:        tlsConfig,
+		MaxRetryAttempts: maxRetryAttempts,
+	}
+	httpClient, err := newClient(clientConfig{
+		ResponseTimeout: responseTimeout,
+		TLSConfig:       tlsConfig,
+		Username:        username,
+		Password:        password,
+		MaxAttempts:     maxRetryAttempts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	api.client = httpClient
+	return api, nil
+}
+
+// getClient lazily builds the shared HTTP client from the struct's fields, so ServerAPI
+// values created as plain struct literals (without going through NewServerAPI) keep working.
+func (api *ServerAPI) getClient() *client {
+	if api.client == nil {
+		httpClient, err := newClient(clientConfig{
+			ResponseTimeout: api.ResponseTimeout,
+			TLSConfig:       api.TLSConfig,
+			Username:        api.Username,
+			Password:        api.Password,
+			MaxAttempts:     api.MaxRetryAttempts,
+		})
+		if err != nil {
+			log.Printf("Could not build Jenkins HTTP client from TLSConfig, falling back to http.DefaultClient: %v\n", err)
+			httpClient = &client{
+				httpClient:  http.DefaultClient,
+				username:    api.Username,
+				password:    api.Password,
+				maxAttempts: defaultMaxAttempts,
+				retryDelay:  defaultRetryBaseDelay,
+			}
+		}
+		api.client = httpClient
+	}
+	return api.client
+}
+
+// jobPath translates a hierarchical job name such as "folderA/folderB/jobC" into the
+// Jenkins REST path segment "job/folderA/job/folderB/job/jobC".
+func jobPath(job string) string {
+	segments := strings.Split(job, "/")
+	for i, segment := range segments {
+		segments[i] = "job/" + segment
+	}
+	return strings.Join(segments, "/")
 }
 
 // GetLastBuildURLForJob will create URL towards a page with LAST job execution result for a particular job
 func (api *ServerAPI) GetLastBuildURLForJob(job string) string {
-	return fmt.Sprintf("%v/job/%v/%v/", api.ServerLocation, job, lastBuild)
+	return fmt.Sprintf("%v/%v/%v/", api.ServerLocation, jobPath(job), lastBuild)
 }
 
 // GetLastCompletedBuildURLForJob will create URL towards a page with LAST COMPLETED job execution result for a particular job
 func (api *ServerAPI) GetLastCompletedBuildURLForJob(job string) string {
-	return fmt.Sprintf("%v/job/%v/%v/", api.ServerLocation, job, lastCompletedBuild)
+	return fmt.Sprintf("%v/%v/%v/", api.ServerLocation, jobPath(job), lastCompletedBuild)
 }
 
 // GetCurrentStatus returns current state for a particular job
@@ -47,13 +130,10 @@ func (api *ServerAPI) GetCurrentStatus(job string) (*JobStatus, error) {
 	return api.GetStatusForJob(job, lastBuild)
 }
 
-// GetStatusForJob returns a status of a specific job run
+// GetStatusForJob returns a status of a specific job run. job may be a hierarchical name
+// such as "folderA/folderB/jobC" for jobs living inside Folders or Multibranch Pipelines.
 func (api *ServerAPI) GetStatusForJob(job string, id string) (*JobStatus, error) {
 	possibleCacheKey := fmt.Sprintf("%s-%s", job, id)
-	if strings.Contains(job,"/") {
-		log.Println("Rejecting StatusForJob since folder structure has been detected and that's not supported: ", job)
-		return nil, errStatusPageNotFound
-	}
 	if id != lastBuild && id != lastCompletedBuild {
 		if api.cachedStatuses == nil {
 			api.cachedStatuses = make(map[string](*JobStatus), 0)
@@ -63,16 +143,16 @@ func (api *ServerAPI) GetStatusForJob(job string, id string) (*JobStatus, error)
 			return cachedValue, nil
 		}
 	}
-	link := fmt.Sprintf("%v/job/%v/%v/api/json?tree=id,result,timestamp,estimatedDuration,building,culprits[fullName],actions[causes[userId,upstreamBuild,upstreamProject,shortDescription]],changeSets[items[author[fullName]]]",
-		api.ServerLocation, job, id)
+	link := fmt.Sprintf("%v/%v/%v/api/json?tree=id,result,timestamp,duration,estimatedDuration,building,culprits[fullName],actions[causes[userId,upstreamBuild,upstreamProject,shortDescription]],changeSets[items[author[fullName]]]",
+		api.ServerLocation, jobPath(job), id)
 	log.Printf("Visiting %v", link)
-	resp, err := http.Get(link)
+	resp, err := api.getClient().get(link)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err, link, job, id)
 	}
 	defer func() { _ = resp.Body.Close() }()
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, errStatusPageNotFound
+	if classified := Classify(resp, nil); classified != nil {
+		return nil, wrapError(classified, link, job, id)
 	}
 	result := &JobStatus{}
 	err = json.NewDecoder(resp.Body).Decode(&result)
@@ -83,18 +163,62 @@ func (api *ServerAPI) GetStatusForJob(job string, id string) (*JobStatus, error)
 }
 
 // GetKnownJobs represents API which gives back list of all known jobs in the Jenkins Server, and their last known
-// (or current, if job is running) state
+// (or current, if job is running) state. Jobs nested inside Folders or Multibranch Pipelines are descended into,
+// up to MaxSubJobsLayer levels deep, and flattened back into "parent/child" names so callers don't need to know
+// the folder structure exists. NewestSubJobsEachLayer caps how many jobs are requested per folder level.
 func (api *ServerAPI) GetKnownJobs() (resultFromJenkins *Status, err error) {
-	resp, err := http.Get(fmt.Sprintf("%v/api/json?tree=jobs[name,color]", api.ServerLocation))
+	maxLayer := api.MaxSubJobsLayer
+	if maxLayer <= 0 {
+		maxLayer = defaultMaxSubJobsLayer
+	}
+	newestEach := api.NewestSubJobsEachLayer
+	if newestEach <= 0 {
+		newestEach = defaultNewestSubJobsEachLayer
+	}
+	resp, err := api.getClient().get(fmt.Sprintf("%v/api/json?tree=%s", api.ServerLocation, jobsTreeParam(maxLayer, newestEach)))
 	if err != nil {
 		return
 	}
 	defer func() { _ = resp.Body.Close() }()
 	resultFromJenkins = &Status{}
 	err = json.NewDecoder(resp.Body).Decode(&resultFromJenkins)
+	if err != nil {
+		return
+	}
+	resultFromJenkins.JobBuildStatus = flattenJobs(resultFromJenkins.JobBuildStatus, "")
 	return resultFromJenkins, nil
 }
 
+// jobsTreeParam builds the Jenkins tree query for "jobs[name,color,jobs[...]]", nested
+// maxLayer levels deep, capping each level's array at newestEach entries via Jenkins'
+// "{0,N}" range syntax.
+func jobsTreeParam(maxLayer, newestEach int) string {
+	param := fmt.Sprintf("jobs[name,color]{0,%d}", newestEach)
+	for i := 0; i < maxLayer; i++ {
+		param = fmt.Sprintf("jobs[name,color,%s]{0,%d}", param, newestEach)
+	}
+	return param
+}
+
+// flattenJobs walks the (possibly nested) job tree returned by Jenkins and returns a flat
+// list with sub-job names expanded into "parent/child" hierarchical names.
+func flattenJobs(jobs []JobBuildStatus, prefix string) []JobBuildStatus {
+	flat := make([]JobBuildStatus, 0, len(jobs))
+	for _, job := range jobs {
+		name := job.Name
+		if prefix != "" {
+			name = prefix + "/" + name
+		}
+		if len(job.Jobs) > 0 {
+			flat = append(flat, flattenJobs(job.Jobs, name)...)
+			continue
+		}
+		job.Name = name
+		flat = append(flat, job)
+	}
+	return flat
+}
+
 // Causes takes a known job status and finds people ("causes") that caused it to start,
 // returning a CSV list of people.
 // It might need to visit server again in case it has to follow casual chain
@@ -128,11 +252,11 @@ func (api *ServerAPI) CausesOfFailures(name, id string) []string {
 		}
 		statusIterator, err := api.GetStatusForJob(name, id)
 		if err != nil {
-			if err == errStatusPageNotFound {
+			if errors.Is(err, ErrNotFound) {
 				id = strconv.Itoa(currentID - 1)
 				continue
 			}
-			log.Println("Could not fetch causes: ", err)
+			log.Println("Could not fetch causes, giving up: ", err)
 			break
 		}
 		if statusIterator.Result == "SUCCESS" || statusIterator.Result == "FIXED" {
@@ -196,22 +320,23 @@ func (api *ServerAPI) addCauses(set map[string]bool, upstreamProject string, ups
 	return nil
 }
 
-// GetFailedTestListFor will return list of test cases that failed in a particular job execution
+// GetFailedTestListFor will return list of test cases that failed in a particular job execution.
+// job may be a hierarchical name for jobs living inside Folders or Multibranch Pipelines.
 func (api *ServerAPI) GetFailedTestListFor(job, id string) (results []TestCase, err error) {
-	link := fmt.Sprintf("%v/job/%s/%s/testReport/api/json?tree=suites[cases[className,name,status,errorStackTrace]]", api.ServerLocation, job, id)
+	link := fmt.Sprintf("%v/%s/%s/testReport/api/json?tree=suites[cases[className,name,status,errorStackTrace]]", api.ServerLocation, jobPath(job), id)
 	log.Printf("Visiting %s\n", link)
-	resp, err := http.Get(link)
+	resp, err := api.getClient().get(link)
 	if err != nil {
-		return
+		return nil, wrapError(err, link, job, id)
 	}
 	defer func() { _ = resp.Body.Close() }()
-	if resp.StatusCode == 404 {
-		return nil, errors.New("no test report found")
+	if classified := Classify(resp, nil); classified != nil {
+		return nil, wrapError(classified, link, job, id)
 	}
 	var received TestCaseResult
 	err = json.NewDecoder(resp.Body).Decode(&received)
 	if err != nil {
-		return
+		return nil, wrapError(err, link, job, id)
 	}
 
 	results = make([]TestCase, 0)
@@ -230,78 +355,162 @@ func (api *ServerAPI) GetFailedTestList(job string) ([]TestCase, error) {
 	return api.GetFailedTestListFor(job, "lastFailedBuild")
 }
 
-func fetchSizeForLastLogLines(linkForSize string) (int, error) {
-	resp, err := http.Head(linkForSize)
-	if err != nil {
-		return 0, err
-	}
-	defer func() { _ = resp.Body.Close() }()
-	if resp.StatusCode != 200 {
-		return 0, fmt.Errorf("could not fetch log size, statusCode=%d", resp.StatusCode)
-	}
-	textSize := resp.Header.Get("X-Text-Size")
-	if textSize == "" {
-		return 0, errors.New("size not received from server HEAD call")
-	}
+// consolePollInterval is how long StreamConsole sleeps between polls while a build is
+// still running.
+const consolePollInterval = 1 * time.Second
 
-	return strconv.Atoi(textSize)
-}
-
-func fetchLinesForLastLogLines(link string, lineCount int) ([]string, error) {
-	respData, err := http.Get(link)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = respData.Body.Close() }()
-	if respData.StatusCode != 200 {
-		return nil, fmt.Errorf("not able to fetch console output: %d", respData.StatusCode)
-	}
-	data, err := ioutil.ReadAll(respData.Body)
-	if err != nil {
-		return nil, err
-	}
-	var dataAsString []string
-	nl, endIter := 0, len(data)-1
-	for i := endIter; i >= 0 && nl < lineCount; i-- {
-		if data[i] == '\n' && i != endIter {
-			nl++
-			cleanLine := matcherForHTMLAndWeirdCharacters.ReplaceAllString(string(data[i+1:endIter]), "")
-			dataAsString = append(dataAsString, cleanLine)
-			endIter = i
+// StreamConsole follows a build's console output live via Jenkins' logText/progressiveText
+// endpoint, emitting newly received lines on out until X-More-Data is absent or ctx is
+// cancelled.
+func (api *ServerAPI) StreamConsole(ctx context.Context, job, id string, out chan<- string) error {
+	link := fmt.Sprintf("%v/%s/%s/logText/progressiveText", api.ServerLocation, jobPath(job), id)
+	var start int64
+	var trailing string
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
 		}
+		resp, err := api.getClient().get(fmt.Sprintf("%s?start=%d", link, start))
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		moreData := resp.Header.Get("X-More-Data") == "true"
+		if nextSize := resp.Header.Get("X-Text-Size"); nextSize != "" {
+			if parsed, parseErr := strconv.ParseInt(nextSize, 10, 64); parseErr == nil {
+				start = parsed
+			}
+		}
+		_ = resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		trailing += string(data)
+		lines := strings.Split(trailing, "\n")
+		trailing = lines[len(lines)-1]
+		for _, line := range lines[:len(lines)-1] {
+			out <- matcherForHTMLAndWeirdCharacters.ReplaceAllString(line, "")
+		}
+		if !moreData {
+			if trailing != "" {
+				out <- matcherForHTMLAndWeirdCharacters.ReplaceAllString(trailing, "")
+			}
+			return nil
+		}
+		time.Sleep(consolePollInterval)
 	}
-	for i := 0; i < len(dataAsString)/2; i++ {
-		dataAsString[i], dataAsString[len(dataAsString)-i-1] = dataAsString[len(dataAsString)-i-1], dataAsString[i]
-	}
-	return dataAsString, nil
 }
 
-// GetLastLogLines returns lineCount lines from the console output of a job run
+// GetLastLogLines returns the tail lineCount lines from the console output of a job run. It
+// is a convenience wrapper over StreamConsole that waits for the log to finish rather than
+// following it live. job may be a hierarchical name for jobs living inside Folders or
+// Multibranch Pipelines.
 func (api *ServerAPI) GetLastLogLines(job, id string, lineCount int) ([]string, error) {
-	linkForSize := fmt.Sprintf("%v/job/%s/%s/logText/progressiveHtml", api.ServerLocation, job, id)
-	size, err := fetchSizeForLastLogLines(linkForSize)
-	if err != nil {
+	out := make(chan string)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- api.StreamConsole(context.Background(), job, id, out)
+		close(out)
+	}()
+	var all []string
+	for line := range out {
+		all = append(all, line)
+	}
+	if err := <-errChan; err != nil {
 		return nil, err
 	}
-	return fetchLinesForLastLogLines(fmt.Sprintf("%s?start=%d", linkForSize, size-sizeOfSuffix), lineCount)
+	if len(all) > lineCount {
+		all = all[len(all)-lineCount:]
+	}
+	return all, nil
 }
 
-// RunJob will execute a job (expected - without parameters)
+// RunJob will execute a job (expected - without parameters). job may be a hierarchical
+// name for jobs living inside Folders or Multibranch Pipelines.
 func (api *ServerAPI) RunJob(job string) error {
-	linkForRun := fmt.Sprintf("%v/job/%s/build?delay=0sec", api.ServerLocation, job)
-	log.Printf("Visiting %s\n", linkForRun)
-	req, err := http.NewRequest("POST", linkForRun, nil)
-	req.SetBasicAuth(api.Username, api.Password)
-	if err != nil {
-		return err
+	_, err := api.RunJobWithParams(job, nil)
+	return err
+}
+
+// RunJobWithParams triggers a build, POSTing url-encoded params to /buildWithParameters, or
+// falling back to RunJob's plain /build when params is empty. It returns the "Location"
+// response header, the URL of the resulting queue item, which GetQueueItem can poll to
+// resolve the actual build id once Jenkins schedules it onto an executor.
+func (api *ServerAPI) RunJobWithParams(job string, params map[string]string) (string, error) {
+	var linkForRun, contentType string
+	var body io.Reader
+	if len(params) == 0 {
+		linkForRun = fmt.Sprintf("%v/%s/build?delay=0sec", api.ServerLocation, jobPath(job))
+	} else {
+		linkForRun = fmt.Sprintf("%v/%s/buildWithParameters", api.ServerLocation, jobPath(job))
+		values := url.Values{}
+		for key, value := range params {
+			values.Set(key, value)
+		}
+		body = strings.NewReader(values.Encode())
+		contentType = "application/x-www-form-urlencoded"
 	}
-	respData, err := http.DefaultClient.Do(req)
+	log.Printf("Visiting %s\n", linkForRun)
+	respData, err := api.getClient().post(linkForRun, body, contentType)
 	if err != nil {
-		return err
+		return "", wrapError(err, linkForRun, job, "")
 	}
 	defer func() { _ = respData.Body.Close() }()
+	if classified := Classify(respData, nil); classified != nil {
+		return "", wrapError(classified, linkForRun, job, "")
+	}
 	if respData.StatusCode != 201 {
-		return fmt.Errorf("not able to run job: %d", respData.StatusCode)
+		return "", wrapError(fmt.Errorf("unexpected status %d triggering build", respData.StatusCode), linkForRun, job, "")
+	}
+	return respData.Header.Get("Location"), nil
+}
+
+// QueueItem represents an item in Jenkins' build queue, as returned by
+// /queue/item/<id>/api/json.
+type QueueItem struct {
+	ID         int64       `json:"id"`
+	Why        string      `json:"why"`
+	Cancelled  bool        `json:"cancelled"`
+	Executable *Executable `json:"executable"`
+}
+
+// Executable identifies the actual build a queue item turned into once Jenkins schedules it.
+type Executable struct {
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+}
+
+// queueItemPollInterval is how long GetQueueItem sleeps between polls of a queue item.
+const queueItemPollInterval = 1 * time.Second
+
+// GetQueueItem polls location (as returned by RunJobWithParams) until Jenkins has scheduled
+// the queued item onto an executor, i.e. until "executable.number" appears in the response,
+// so callers can resolve the actual build id of a job they just triggered.
+func (api *ServerAPI) GetQueueItem(location string) (*QueueItem, error) {
+	link := strings.TrimSuffix(location, "/") + "/api/json"
+	for {
+		resp, err := api.getClient().get(link)
+		if err != nil {
+			return nil, wrapError(err, link, "", "")
+		}
+		if classified := Classify(resp, nil); classified != nil {
+			_ = resp.Body.Close()
+			return nil, wrapError(classified, link, "", "")
+		}
+		item := &QueueItem{}
+		err = json.NewDecoder(resp.Body).Decode(item)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, wrapError(err, link, "", "")
+		}
+		if item.Cancelled {
+			return nil, wrapError(fmt.Errorf("queue item was cancelled"), link, "", "")
+		}
+		if item.Executable != nil && item.Executable.Number != 0 {
+			return item, nil
+		}
+		time.Sleep(queueItemPollInterval)
 	}
-	return nil
 }
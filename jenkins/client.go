@@ -0,0 +1,224 @@
+package jenkins
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultResponseTimeout = 30 * time.Second
+	defaultMaxAttempts     = 3
+	defaultRetryBaseDelay  = 200 * time.Millisecond
+)
+
+// TLSConfig controls how ServerAPI validates the Jenkins server's certificate and, if the
+// server requires it, authenticates itself with a client certificate.
+type TLSConfig struct {
+	InsecureSkipVerify bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+}
+
+// client is the shared HTTP plumbing behind every ServerAPI call: it owns the *http.Client
+// (with its timeout and TLS setup), applies basic auth, attaches a CSRF crumb to mutating
+// requests, and retries transient failures with exponential backoff and jitter (the
+// "retryingDoer" behaviour), similar to telegraf's jenkins plugin client.
+type client struct {
+	httpClient  *http.Client
+	username    string
+	password    string
+	maxAttempts int
+	retryDelay  time.Duration
+}
+
+type clientConfig struct {
+	ResponseTimeout time.Duration
+	TLSConfig       TLSConfig
+	Username        string
+	Password        string
+	// MaxAttempts bounds how many attempts in total a request gets while it keeps failing
+	// transiently (ErrServer, ErrTransport, ErrRateLimited). Zero means defaultMaxAttempts.
+	MaxAttempts int
+}
+
+func newClient(config clientConfig) (*client, error) {
+	tlsConfig, err := buildTLSConfig(config.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	timeout := config.ResponseTimeout
+	if timeout <= 0 {
+		timeout = defaultResponseTimeout
+	}
+	maxAttempts := config.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	return &client{
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		username:    config.Username,
+		password:    config.Password,
+		maxAttempts: maxAttempts,
+		retryDelay:  defaultRetryBaseDelay,
+	}, nil
+}
+
+func buildTLSConfig(config TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CAFile != "" {
+		caCert, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA file %s: %v", config.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("could not parse CA file %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" && config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate/key: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// get performs an authenticated GET request, retrying transient failures.
+func (c *client) get(requestURL string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.authenticate(req)
+	return c.do(req)
+}
+
+// head performs an authenticated HEAD request, retrying transient failures.
+func (c *client) head(requestURL string) (*http.Response, error) {
+	req, err := http.NewRequest("HEAD", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.authenticate(req)
+	return c.do(req)
+}
+
+// post performs an authenticated POST request, attaching a CSRF crumb first if the server
+// has crumb issuing enabled, and retrying transient failures.
+func (c *client) post(requestURL string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest("POST", requestURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	c.authenticate(req)
+	if err := c.attachCrumb(req); err != nil {
+		log.Printf("Could not fetch CSRF crumb, continuing without it: %v\n", err)
+	}
+	return c.do(req)
+}
+
+func (c *client) authenticate(req *http.Request) {
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+}
+
+// do is the retryingDoer: it runs req, classifying the outcome with Classify, and retries
+// ErrServer/ErrTransport/ErrRateLimited, making at most c.maxAttempts attempts in total,
+// with exponential backoff and jitter, honoring a "Retry-After" response header when the server sends one.
+func (c *client) do(req *http.Request) (*http.Response, error) {
+	var lastErr error
+	for attempt := 0; attempt < c.maxAttempts; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		resp, err := c.httpClient.Do(req)
+		classified := Classify(resp, err)
+		if classified == nil || !isRetryable(classified) {
+			return resp, err
+		}
+		lastErr = classified
+		delay := c.nextRetryDelay(attempt, resp)
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		if attempt == c.maxAttempts-1 {
+			break
+		}
+		log.Printf("Retrying %s %s after %v (attempt %d/%d): %v\n", req.Method, req.URL, delay, attempt+1, c.maxAttempts, classified)
+		time.Sleep(delay)
+	}
+	return nil, lastErr
+}
+
+// maxBackoffShift caps the exponent used in nextRetryDelay's backoff so a large configured
+// MaxRetryAttempts can't shift 1<<attempt into overflow.
+const maxBackoffShift = 20
+
+// nextRetryDelay honors a numeric "Retry-After" response header if present, otherwise backs
+// off exponentially from c.retryDelay with up to 100% jitter.
+func (c *client) nextRetryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(retryAfter); err == nil {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if attempt > maxBackoffShift {
+		attempt = maxBackoffShift
+	}
+	backoff := c.retryDelay * time.Duration(1<<uint(attempt))
+	jitter := time.Duration(rand.Int63n(int64(backoff) + 1))
+	return backoff + jitter
+}
+
+type crumbIssuerResponse struct {
+	Crumb             string `json:"crumb"`
+	CrumbRequestField string `json:"crumbRequestField"`
+}
+
+// attachCrumb fetches a CSRF crumb from /crumbIssuer/api/json and sets it as a header on
+// req, as Jenkins requires for any mutating request once CSRF protection is enabled. When
+// the crumb issuer is absent (CSRF protection disabled) it returns an error and leaves req
+// unchanged; post logs that error and sends the request without a crumb.
+func (c *client) attachCrumb(req *http.Request) error {
+	base := fmt.Sprintf("%s://%s", req.URL.Scheme, req.URL.Host)
+	resp, err := c.get(fmt.Sprintf("%s/crumbIssuer/api/json", base))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("crumbIssuer returned status %d", resp.StatusCode)
+	}
+	var issuer crumbIssuerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&issuer); err != nil {
+		return err
+	}
+	req.Header.Set(issuer.CrumbRequestField, issuer.Crumb)
+	return nil
+}
@@ -0,0 +1,82 @@
+package jenkins
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors classifying why a call against the Jenkins server failed. Use errors.Is
+// to test for one of these; an APIError wraps whichever one applies together with the
+// request context, but the sentinel itself remains reachable through Unwrap.
+var (
+	ErrNotFound     = errors.New("jenkins: not found")
+	ErrUnauthorized = errors.New("jenkins: unauthorized")
+	ErrForbidden    = errors.New("jenkins: forbidden")
+	ErrRateLimited  = errors.New("jenkins: rate limited")
+	ErrServer       = errors.New("jenkins: server error")
+	ErrTransport    = errors.New("jenkins: transport error")
+)
+
+// APIError wraps the error of a failed request with the request context. That error is
+// usually one of the sentinel Err* values, but can also be e.g. a JSON decoding error; it
+// unwraps to whatever it wraps, so errors.Is/errors.As still see through it.
+type APIError struct {
+	URL   string
+	Job   string
+	Build string
+	Err   error
+}
+
+func (e *APIError) Error() string {
+	if e.Job != "" {
+		return fmt.Sprintf("jenkins: %v (job=%s, build=%s, url=%s)", e.Err, e.Job, e.Build, e.URL)
+	}
+	return fmt.Sprintf("jenkins: %v (url=%s)", e.Err, e.URL)
+}
+
+// Unwrap exposes the wrapped sentinel error so errors.Is(err, jenkins.ErrNotFound) works
+// through an APIError.
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// wrapError attaches request context (url, job, build) to err, returning nil if err is nil.
+func wrapError(err error, requestURL, job, build string) error {
+	if err == nil {
+		return nil
+	}
+	return &APIError{URL: requestURL, Job: job, Build: build, Err: err}
+}
+
+// Classify turns an HTTP response and/or a transport-level error into one of the sentinel
+// Err* values, so callers can distinguish "job missing" from "auth failed" from "server
+// down" instead of getting an opaque error string. It returns nil for successful responses.
+// A 4xx status other than 401, 403, 404 and 429 yields an error that matches none of them.
+func Classify(resp *http.Response, err error) error {
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTransport, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case resp.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case resp.StatusCode >= 500:
+		return ErrServer
+	case resp.StatusCode >= 400:
+		return fmt.Errorf("jenkins: unexpected status %d", resp.StatusCode)
+	default:
+		return nil
+	}
+}
+
+// isRetryable reports whether err is one of the transient classifications that
+// retryingDoer should retry rather than give up on immediately.
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrServer) || errors.Is(err, ErrTransport) || errors.Is(err, ErrRateLimited)
+}
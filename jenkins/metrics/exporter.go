@@ -0,0 +1,189 @@
+// Package metrics polls a set of Jenkins jobs on a timer and exposes their build health on
+// a plain-text Prometheus/OpenMetrics /metrics endpoint.
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/milanaleksic/jenkins_ping/jenkins"
+)
+
+// buildResultCode encodes Jenkins build results the way the telegraf jenkins plugin does,
+// so dashboards built against that convention keep working here.
+var buildResultCode = map[string]float64{
+	"SUCCESS":   0,
+	"FAILURE":   1,
+	"NOT_BUILT": 2,
+	"UNSTABLE":  3,
+	"ABORTED":   4,
+}
+
+// Api is the subset of jenkins.ServerAPI (or jenkins.MockAPI) the exporter needs to scrape.
+type Api interface {
+	GetKnownJobs() (*jenkins.Status, error)
+	GetCurrentStatus(job string) (*jenkins.JobStatus, error)
+	GetFailedTestListFor(job, id string) ([]jenkins.TestCase, error)
+}
+
+// Config controls which jobs get scraped, how often, and how deep folder-organized
+// Jenkins instances are descended into.
+type Config struct {
+	ListenAddr     string
+	ScrapeInterval time.Duration
+	// JobInclude/JobExclude are shell globs (as matched by path.Match) applied to job
+	// names. JobExclude wins over JobInclude; an empty JobInclude means "everything".
+	JobInclude []string
+	JobExclude []string
+	// MaxBuildAge causes stale builds to be skipped instead of reported, so a job nobody
+	// has run in months doesn't keep showing up as a fresh data point.
+	MaxBuildAge time.Duration
+	// MaxSubJobsLayer holds the configured folder depth for GetKnownJobs. The Exporter
+	// itself does not read it; the depth is whatever the Api itself is configured with
+	// (e.g. ServerAPI.MaxSubJobsLayer).
+	MaxSubJobsLayer int
+}
+
+// Exporter periodically polls Jenkins and serves the resulting gauges on /metrics.
+type Exporter struct {
+	api    Api
+	config Config
+
+	mu      sync.RWMutex
+	samples []sample
+}
+
+type sample struct {
+	job               string
+	result            float64
+	resultKnown       bool
+	duration          float64
+	estimatedDuration float64
+	failedTests       float64
+	timestamp         float64
+}
+
+// NewExporter wires an Api implementation (ServerAPI or MockAPI) to the given Config.
+func NewExporter(api Api, config Config) *Exporter {
+	return &Exporter{api: api, config: config}
+}
+
+// Start runs the scrape loop in the background and serves /metrics on config.ListenAddr.
+// It blocks until the HTTP server stops, so callers typically run it in a goroutine.
+func (e *Exporter) Start() error {
+	go e.scrapeLoop()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", e.handleMetrics)
+	log.Printf("Serving Jenkins metrics on %s/metrics\n", e.config.ListenAddr)
+	return http.ListenAndServe(e.config.ListenAddr, mux)
+}
+
+func (e *Exporter) scrapeLoop() {
+	e.scrape()
+	ticker := time.NewTicker(e.config.ScrapeInterval)
+	for range ticker.C {
+		e.scrape()
+	}
+}
+
+func (e *Exporter) scrape() {
+	status, err := e.api.GetKnownJobs()
+	if err != nil {
+		log.Printf("metrics: could not fetch known jobs: %v\n", err)
+		return
+	}
+	collected := make([]sample, 0, len(status.JobBuildStatus))
+	for _, job := range status.JobBuildStatus {
+		if s, ok := e.scrapeJob(job.Name); ok {
+			collected = append(collected, s)
+		}
+	}
+	e.mu.Lock()
+	e.samples = collected
+	e.mu.Unlock()
+}
+
+func (e *Exporter) scrapeJob(name string) (sample, bool) {
+	if !e.included(name) {
+		return sample{}, false
+	}
+	jobStatus, err := e.api.GetCurrentStatus(name)
+	if err != nil {
+		log.Printf("metrics: could not fetch status for %s: %v\n", name, err)
+		return sample{}, false
+	}
+	buildTime := time.Unix(0, jobStatus.Timestamp*int64(time.Millisecond))
+	if e.config.MaxBuildAge > 0 && time.Since(buildTime) > e.config.MaxBuildAge {
+		log.Printf("metrics: %s last build is older than max_build_age, skipping\n", name)
+		return sample{}, false
+	}
+	failedTests, err := e.api.GetFailedTestListFor(name, "lastBuild")
+	if err != nil {
+		log.Printf("metrics: could not fetch failed tests for %s: %v\n", name, err)
+	}
+	result, resultKnown := buildResultCode[jobStatus.Result]
+	if !resultKnown {
+		log.Printf("metrics: %s has no recognized build result yet (result=%q), omitting jenkins_build_result\n", name, jobStatus.Result)
+	}
+	return sample{
+		job:               name,
+		result:            result,
+		resultKnown:       resultKnown,
+		duration:          float64(jobStatus.Duration) / 1000,
+		estimatedDuration: float64(jobStatus.EstimatedDuration) / 1000,
+		failedTests:       float64(len(failedTests)),
+		timestamp:         float64(jobStatus.Timestamp) / 1000,
+	}, true
+}
+
+// included applies the job_include/job_exclude glob filters, following the telegraf
+// jenkins plugin's convention: exclude wins, and an empty include list matches everything.
+func (e *Exporter) included(name string) bool {
+	for _, pattern := range e.config.JobExclude {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+	if len(e.config.JobInclude) == 0 {
+		return true
+	}
+	for _, pattern := range e.config.JobInclude {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Exporter) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	sorted := make([]sample, len(e.samples))
+	copy(sorted, e.samples)
+	e.mu.RUnlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].job < sorted[j].job })
+
+	resultSamples := make([]sample, 0, len(sorted))
+	for _, s := range sorted {
+		if s.resultKnown {
+			resultSamples = append(resultSamples, s)
+		}
+	}
+	writeGauge(w, "jenkins_build_result", "Build result, encoded as SUCCESS=0 FAILURE=1 NOT_BUILT=2 UNSTABLE=3 ABORTED=4", resultSamples, func(s sample) float64 { return s.result })
+	writeGauge(w, "jenkins_build_duration_seconds", "Duration of the last build, in seconds", sorted, func(s sample) float64 { return s.duration })
+	writeGauge(w, "jenkins_build_estimated_duration_seconds", "Estimated/queued duration of the last build, in seconds", sorted, func(s sample) float64 { return s.estimatedDuration })
+	writeGauge(w, "jenkins_build_failed_tests", "Number of failed tests in the last build", sorted, func(s sample) float64 { return s.failedTests })
+	writeGauge(w, "jenkins_build_timestamp_seconds", "Unix timestamp of the last build", sorted, func(s sample) float64 { return s.timestamp })
+}
+
+func writeGauge(w http.ResponseWriter, name, help string, samples []sample, value func(sample) float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s{job=%q} %v\n", name, s.job, value(s))
+	}
+}
@@ -0,0 +1,82 @@
+package jenkins
+
+// Status is the top-level response from Jenkins' /api/json endpoint, listing known jobs.
+type Status struct {
+	JobBuildStatus []JobBuildStatus `json:"jobs"`
+}
+
+// JobBuildStatus is a single job as returned by GetKnownJobs. Jobs holds nested sub-jobs one
+// folder level down, when jobsTreeParam descended into it; flattenJobs expands those back
+// into "parent/child" names.
+type JobBuildStatus struct {
+	Name  string           `json:"name"`
+	Color string           `json:"color"`
+	Jobs  []JobBuildStatus `json:"jobs"`
+}
+
+// JobStatus is the state of a single job run, as returned by GetCurrentStatus/GetStatusForJob.
+type JobStatus struct {
+	Result            string      `json:"result"`
+	Timestamp         int64       `json:"timestamp"`
+	Duration          int64       `json:"duration"`
+	EstimatedDuration int64       `json:"estimatedDuration"`
+	Building          bool        `json:"building"`
+	Culprits          []Culprit   `json:"culprits"`
+	Actions           []Action    `json:"actions"`
+	ChangeSets        []ChangeSet `json:"changeSets"`
+}
+
+// Culprit identifies a person Jenkins considers responsible for a build.
+type Culprit struct {
+	FullName string `json:"fullName"`
+}
+
+// Action groups the Causes that triggered a build.
+type Action struct {
+	Causes []Cause `json:"causes"`
+}
+
+// Cause is one reason a build was triggered, e.g. a user, an upstream build, or an SCM change.
+type Cause struct {
+	UserID           string `json:"userId"`
+	UpstreamBuild    int    `json:"upstreamBuild"`
+	UpstreamProject  string `json:"upstreamProject"`
+	ShortDescription string `json:"shortDescription"`
+}
+
+// ChangeSet is one SCM change set included in a build.
+type ChangeSet struct {
+	Items []ChangeSetItem `json:"items"`
+}
+
+// ChangeSetItem is a single commit within a ChangeSet.
+type ChangeSetItem struct {
+	Author Culprit `json:"author"`
+}
+
+// TestCase is a single JUnit test case as returned by GetFailedTestListFor.
+type TestCase struct {
+	ClassName       string `json:"className"`
+	Name            string `json:"name"`
+	Status          string `json:"status"`
+	ErrorStackTrace string `json:"errorStackTrace"`
+}
+
+// TestCaseResult is the top-level testReport response, grouping TestCases into suites.
+type TestCaseResult struct {
+	Suites []TestSuite `json:"suites"`
+}
+
+// TestSuite is one suite within a TestCaseResult.
+type TestSuite struct {
+	Cases []TestCase `json:"cases"`
+}
+
+// mapKeysToSlice returns the keys of set as a slice, in no particular order.
+func mapKeysToSlice(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	return keys
+}
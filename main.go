@@ -5,6 +5,9 @@ import (
 	"log"
 	"strings"
 	"time"
+
+	"github.com/milanaleksic/jenkins_ping/jenkins"
+	"github.com/milanaleksic/jenkins_ping/jenkins/metrics"
 )
 
 type View interface {
@@ -18,6 +21,14 @@ type Options struct {
 	SimpleInterface bool
 	Mock            bool
 	Refresh         time.Duration
+	MetricsAddr     string
+	JobInclude      []string
+	JobExclude      []string
+	MaxBuildAge     time.Duration
+	MaxSubJobsLayer int
+	Username        string
+	Password        string
+	TLSConfig       jenkins.TLSConfig
 }
 
 var options Options
@@ -28,6 +39,17 @@ func init() {
 	simpleInterface := flag.Bool("simple", false, "Force simple interface (keeps feeding into console)")
 	mock := flag.Bool("mock", false, "Use mocked data to see how program behaves")
 	refresh := flag.Duration("refresh", 15*time.Second, "How often to refresh Jenkins status")
+	metricsAddr := flag.String("metrics-addr", "", "If set (e.g. \":9186\"), also serve Prometheus metrics on this address, alongside the interactive UI")
+	jobInclude := flag.String("job-include", "", "CSV of globs of job names to scrape metrics for (default: all)")
+	jobExclude := flag.String("job-exclude", "", "CSV of globs of job names to exclude from metrics scraping")
+	maxBuildAge := flag.Duration("max-build-age", 0, "Skip builds older than this when scraping metrics (0 disables the check)")
+	maxSubJobsLayer := flag.Int("max-sub-jobs-layer", 2, "How many folder levels deep to descend into when scraping metrics")
+	username := flag.String("username", "", "Username for basic auth against the Jenkins server")
+	password := flag.String("password", "", "Password for basic auth against the Jenkins server")
+	tlsInsecureSkipVerify := flag.Bool("tls-insecure-skip-verify", false, "Skip TLS certificate verification (e.g. for a self-signed Jenkins server)")
+	tlsCAFile := flag.String("tls-ca-file", "", "Path to a CA certificate to trust for the Jenkins server")
+	tlsCertFile := flag.String("tls-cert-file", "", "Path to a client certificate for TLS client auth")
+	tlsKeyFile := flag.String("tls-key-file", "", "Path to the client certificate's private key")
 	flag.Parse()
 	options = Options{
 		Jobs:            strings.Split(*jobs, ","),
@@ -35,7 +57,64 @@ func init() {
 		SimpleInterface: *simpleInterface,
 		Mock:            *mock,
 		Refresh:         *refresh,
+		MetricsAddr:     *metricsAddr,
+		JobInclude:      splitNonEmpty(*jobInclude),
+		JobExclude:      splitNonEmpty(*jobExclude),
+		MaxBuildAge:     *maxBuildAge,
+		MaxSubJobsLayer: *maxSubJobsLayer,
+		Username:        *username,
+		Password:        *password,
+		TLSConfig: jenkins.TLSConfig{
+			InsecureSkipVerify: *tlsInsecureSkipVerify,
+			CAFile:             *tlsCAFile,
+			CertFile:           *tlsCertFile,
+			KeyFile:            *tlsKeyFile,
+		},
+	}
+}
+
+// splitNonEmpty splits a CSV flag value, returning nil instead of []string{""} when empty.
+func splitNonEmpty(csv string) []string {
+	if csv == "" {
+		return nil
 	}
+	return strings.Split(csv, ",")
+}
+
+// newMetricsAPI builds the jenkins API the metrics exporter polls, independent of the
+// interactive UI's own API instance so the two subsystems can be run separately.
+func newMetricsAPI() metrics.Api {
+	if options.Mock {
+		return &jenkins.MockAPI{}
+	}
+	return &jenkins.ServerAPI{
+		ServerLocation:  options.Server,
+		Username:        options.Username,
+		Password:        options.Password,
+		TLSConfig:       options.TLSConfig,
+		MaxSubJobsLayer: options.MaxSubJobsLayer,
+	}
+}
+
+// startMetricsExporter starts the /metrics HTTP endpoint in the background if -metrics-addr
+// was set, so the tool can double as a monitoring agent alongside the UI.
+func startMetricsExporter() {
+	if options.MetricsAddr == "" {
+		return
+	}
+	exporter := metrics.NewExporter(newMetricsAPI(), metrics.Config{
+		ListenAddr:      options.MetricsAddr,
+		ScrapeInterval:  options.Refresh,
+		JobInclude:      options.JobInclude,
+		JobExclude:      options.JobExclude,
+		MaxBuildAge:     options.MaxBuildAge,
+		MaxSubJobsLayer: options.MaxSubJobsLayer,
+	})
+	go func() {
+		if err := exporter.Start(); err != nil {
+			log.Println("Metrics exporter stopped:", err)
+		}
+	}()
 }
 
 func mainLoop(feedbackChannel chan Command, ui *View) {
@@ -44,7 +123,11 @@ func mainLoop(feedbackChannel chan Command, ui *View) {
 		api = &MockApi{}
 	} else {
 		api = &JenkinsApi{
-			ServerLocation: options.Server,
+			ServerLocation:  options.Server,
+			Username:        options.Username,
+			Password:        options.Password,
+			TLSConfig:       options.TLSConfig,
+			MaxSubJobsLayer: options.MaxSubJobsLayer,
 		}
 	}
 	controller := Controller{
@@ -74,6 +157,10 @@ func mainLoop(feedbackChannel chan Command, ui *View) {
 				controller.VisitPreviousJob(x.job)
 			case CmdTestsForJobGroup:
 				controller.ShowTests(x.job)
+			case CmdRunJobGroup:
+				controller.RunJobWithParams(x.job)
+			case CmdStreamLogsGroup:
+				go controller.StreamLogs(x.job)
 			}
 		case <-ticker.C:
 			controller.RefreshNodeInformation()
@@ -84,6 +171,7 @@ func mainLoop(feedbackChannel chan Command, ui *View) {
 }
 
 func main() {
+	startMetricsExporter()
 	var feedbackChannel = make(chan Command)
 	var ui View
 	var err error